@@ -0,0 +1,166 @@
+// Command mesoslog is the CLI entry point for the go-mesoslog tools: fetching
+// and tailing Mesos sandbox logs and serving cluster metrics.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/containx/go-mesoslog/mesoslog"
+	"github.com/containx/go-mesoslog/mesoslog/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: mesoslog <get|tail|exporter> [flags]")
+		os.Exit(1)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "get":
+		err = runGet(os.Args[2:])
+	case "tail":
+		err = runTail(os.Args[2:])
+	case "exporter":
+		err = runExporter(os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown command %q", os.Args[1])
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func parseLogType(s string) (mesoslog.LogType, error) {
+	switch s {
+	case "stdout":
+		return mesoslog.STDOUT, nil
+	case "stderr":
+		return mesoslog.STDERR, nil
+	default:
+		return mesoslog.STDOUT, fmt.Errorf("unknown log type %q, want stdout or stderr", s)
+	}
+}
+
+func runGet(args []string) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	host := fs.String("master", "localhost", "mesos master host")
+	port := fs.Int("port", 5050, "mesos master port")
+	dir := fs.String("dir", "", "directory to download logs into, instead of printing to stdout")
+	silent := fs.Bool("silent", false, "suppress the progress bar")
+	noProgress := fs.Bool("no-progress", false, "alias for --silent")
+	fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		return fmt.Errorf("usage: mesoslog get [flags] <app> <stdout|stderr>")
+	}
+	appID := fs.Arg(0)
+
+	logtype, err := parseLogType(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	client, err := mesoslog.NewMesosClient(*host, *port)
+	if err != nil {
+		return err
+	}
+
+	var progress mesoslog.ProgressReporter = mesoslog.NoProgress
+	if !*silent && !*noProgress && mesoslog.IsTTY() {
+		progress = mesoslog.NewTTYProgress(appID)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		progress.Finish()
+		cancel()
+	}()
+
+	logs, err := client.GetLog(ctx, appID, logtype, *dir, progress)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range logs {
+		fmt.Println(l.Log)
+	}
+	return nil
+}
+
+func runTail(args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	host := fs.String("master", "localhost", "mesos master host")
+	port := fs.Int("port", 5050, "mesos master port")
+	interval := fs.Int("interval", 2, "poll interval in seconds")
+	fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		return fmt.Errorf("usage: mesoslog tail [flags] <app> <stdout|stderr>")
+	}
+	appID := fs.Arg(0)
+
+	logtype, err := parseLogType(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	client, err := mesoslog.NewMesosClient(*host, *port)
+	if err != nil {
+		return err
+	}
+
+	target := make(chan mesoslog.LogEvent)
+	done := make(chan bool)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		close(done)
+	}()
+
+	tailErr := make(chan error, 1)
+	go func() {
+		tailErr <- client.TailLogToChannel(appID, logtype, *interval, target, done)
+	}()
+
+	for e := range target {
+		fmt.Println(mesoslog.FormatLogEvent(e))
+	}
+	return <-tailErr
+}
+
+func runExporter(args []string) error {
+	fs := flag.NewFlagSet("exporter", flag.ExitOnError)
+	host := fs.String("master", "localhost", "mesos master host")
+	port := fs.Int("port", 5050, "mesos master port")
+	listen := fs.String("listen", ":9110", "address to serve /metrics on")
+	fs.Parse(args)
+
+	client, err := mesoslog.NewMesosClient(*host, *port)
+	if err != nil {
+		return err
+	}
+
+	exp := exporter.New(client)
+	prometheus.MustRegister(exp)
+	go exp.Start()
+	defer exp.Stop()
+
+	http.Handle("/metrics", promhttp.Handler())
+	return http.ListenAndServe(*listen, nil)
+}
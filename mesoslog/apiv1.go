@@ -0,0 +1,338 @@
+package mesoslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// API version identifiers for MesosClientOptions.APIVersion. APIVersionLegacy
+// (the default) talks to the 0.x state.json/files/*.json endpoints this
+// package has always used; APIVersionV1 talks to the versioned Mesos
+// operator HTTP API.
+const (
+	APIVersionLegacy = "0.x"
+	APIVersionV1     = "v1"
+
+	apiV1Path = "/api/v1"
+)
+
+// apiV1Request is the JSON envelope every operator API v1 call POSTs.
+type apiV1Request struct {
+	Type     string        `json:"type"`
+	ReadFile *readFileCall `json:"read_file,omitempty"`
+}
+
+type readFileCall struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+}
+
+// apiV1Response mirrors the subset of the operator API v1 response envelope
+// this client understands.
+type apiV1Response struct {
+	Type     string          `json:"type"`
+	GetState *v1GetState     `json:"get_state,omitempty"`
+	ReadFile *v1ReadFileInfo `json:"read_file,omitempty"`
+}
+
+type v1ReadFileInfo struct {
+	// Data is base64-encoded, matching the proto3 JSON mapping of `bytes`.
+	Data string `json:"data"`
+	Size int64  `json:"size"`
+}
+
+type v1ID struct {
+	Value string `json:"value"`
+}
+
+type v1GetState struct {
+	GetFrameworks struct {
+		Frameworks []struct {
+			FrameworkInfo struct {
+				ID   v1ID   `json:"id"`
+				Name string `json:"name"`
+			} `json:"framework_info"`
+		} `json:"frameworks"`
+	} `json:"get_frameworks"`
+
+	GetTasks struct {
+		Tasks          []v1Task `json:"tasks"`
+		CompletedTasks []v1Task `json:"completed_tasks"`
+	} `json:"get_tasks"`
+
+	GetAgents struct {
+		Agents []struct {
+			AgentInfo struct {
+				ID       v1ID   `json:"id"`
+				Hostname string `json:"hostname"`
+			} `json:"agent_info"`
+			Pid string `json:"pid"`
+		} `json:"agents"`
+	} `json:"get_agents"`
+
+	// GetExecutors is only populated by an agent's GET_STATE response; it
+	// carries the sandbox directory findDirectory needs.
+	GetExecutors struct {
+		Executors          []v1Executor `json:"executors"`
+		CompletedExecutors []v1Executor `json:"completed_executors"`
+	} `json:"get_executors"`
+}
+
+type v1Executor struct {
+	ExecutorInfo struct {
+		ID          v1ID `json:"executor_id"`
+		FrameworkID v1ID `json:"framework_id"`
+	} `json:"executor_info"`
+	Directory string `json:"directory"`
+}
+
+type v1Task struct {
+	TaskID      v1ID   `json:"task_id"`
+	Name        string `json:"name"`
+	State       string `json:"state"`
+	AgentID     v1ID   `json:"agent_id"`
+	FrameworkID v1ID   `json:"framework_id"`
+	ExecutorID  v1ID   `json:"executor_id"`
+	Statuses    []struct {
+		State string `json:"state"`
+	} `json:"statuses"`
+}
+
+// postAPIv1 issues a single operator API v1 call against baseURL+/api/v1 and
+// returns the decoded response envelope.
+func postAPIv1(baseURL, callType string, req apiV1Request) (*apiV1Response, error) {
+	req.Type = callType
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Post(baseURL+apiV1Path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("operator API v1 call %s against %s failed: %s", callType, baseURL, resp.Status)
+	}
+
+	var out apiV1Response
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// getMasterStateV1 fetches cluster state via the operator API v1 GET_STATE
+// call and adapts it into the same masterState shape the 0.x parser produces.
+func getMasterStateV1(masterURL string) (*masterState, error) {
+	resp, err := postAPIv1(masterURL, "GET_STATE", apiV1Request{})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetState == nil {
+		return nil, fmt.Errorf("operator API v1 GET_STATE response missing get_state")
+	}
+
+	legacy := v1StateToLegacyJSON(resp.GetState)
+	var mstate masterState
+	if err := json.Unmarshal(legacy, &mstate); err != nil {
+		return nil, err
+	}
+	return &mstate, nil
+}
+
+// v1StateToLegacyJSON re-shapes a v1 GetState response into the flat,
+// snake_case document produced by 0.x master state.json.
+func v1StateToLegacyJSON(state *v1GetState) []byte {
+	frameworks := make([]map[string]interface{}, 0, len(state.GetFrameworks.Frameworks))
+	for _, f := range state.GetFrameworks.Frameworks {
+		frameworks = append(frameworks, map[string]interface{}{
+			"id":                  f.FrameworkInfo.ID.Value,
+			"name":                f.FrameworkInfo.Name,
+			"tasks":               v1TasksToLegacy(state.GetTasks.Tasks, f.FrameworkInfo.ID.Value),
+			"completed_tasks":     v1TasksToLegacy(state.GetTasks.CompletedTasks, f.FrameworkInfo.ID.Value),
+			"executors":           v1ExecutorsToLegacy(state.GetExecutors.Executors, f.FrameworkInfo.ID.Value),
+			"completed_executors": v1ExecutorsToLegacy(state.GetExecutors.CompletedExecutors, f.FrameworkInfo.ID.Value),
+		})
+	}
+
+	slaves := make([]map[string]interface{}, 0, len(state.GetAgents.Agents))
+	for _, a := range state.GetAgents.Agents {
+		slaves = append(slaves, map[string]interface{}{
+			"id":       a.AgentInfo.ID.Value,
+			"hostname": a.AgentInfo.Hostname,
+			"pid":      a.Pid,
+		})
+	}
+
+	doc, _ := json.Marshal(map[string]interface{}{
+		"frameworks": frameworks,
+		"slaves":     slaves,
+	})
+	return doc
+}
+
+func v1TasksToLegacy(tasks []v1Task, frameworkID string) []map[string]interface{} {
+	out := []map[string]interface{}{}
+	for _, t := range tasks {
+		if t.FrameworkID.Value != frameworkID {
+			continue
+		}
+		statuses := make([]map[string]interface{}, 0, len(t.Statuses))
+		for _, s := range t.Statuses {
+			statuses = append(statuses, map[string]interface{}{"state": s.State})
+		}
+		out = append(out, map[string]interface{}{
+			"id":           t.TaskID.Value,
+			"name":         t.Name,
+			"slave_id":     t.AgentID.Value,
+			"framework_id": t.FrameworkID.Value,
+			"executor_id":  t.ExecutorID.Value,
+			"statuses":     statuses,
+		})
+	}
+	return out
+}
+
+// getSlaveStateV1 fetches a single agent's executors via the operator API v1
+// GET_STATE call and adapts it into the legacy slaveState shape.
+func getSlaveStateV1(baseURL string) (*slaveState, error) {
+	resp, err := postAPIv1(baseURL, "GET_STATE", apiV1Request{})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetState == nil {
+		return nil, fmt.Errorf("operator API v1 GET_STATE response missing get_state")
+	}
+
+	legacy := v1StateToLegacyJSON(resp.GetState)
+	var sstate slaveState
+	if err := json.Unmarshal(legacy, &sstate); err != nil {
+		return nil, err
+	}
+	return &sstate, nil
+}
+
+func v1ExecutorsToLegacy(executors []v1Executor, frameworkID string) []map[string]interface{} {
+	out := []map[string]interface{}{}
+	for _, e := range executors {
+		if e.ExecutorInfo.FrameworkID.Value != frameworkID {
+			continue
+		}
+		out = append(out, map[string]interface{}{
+			"id":        e.ExecutorInfo.ID.Value,
+			"directory": e.Directory,
+		})
+	}
+	return out
+}
+
+// readFileV1 reads [offset, offset+length) of a sandbox file via the
+// operator API v1 READ_FILE call.
+func readFileV1(baseURL, path string, offset, length int) (readRangeResult, error) {
+	resp, err := postAPIv1(baseURL, "READ_FILE", apiV1Request{
+		ReadFile: &readFileCall{Path: path, Offset: int64(offset), Length: int64(length)},
+	})
+	if err != nil {
+		return readRangeResult{}, err
+	}
+	if resp.ReadFile == nil {
+		return readRangeResult{}, fmt.Errorf("operator API v1 READ_FILE response missing read_file")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(resp.ReadFile.Data)
+	if err != nil {
+		return readRangeResult{}, err
+	}
+	return readRangeResult{Data: string(data), Offset: offset + len(data)}, nil
+}
+
+// probeFileLengthV1 asks the operator API v1 for a sandbox file's current
+// size via a zero-length READ_FILE call; unlike the legacy read.json -1
+// offset trick, v1 reports the file's total size directly in the response.
+func probeFileLengthV1(baseURL, path string) (int, error) {
+	resp, err := postAPIv1(baseURL, "READ_FILE", apiV1Request{
+		ReadFile: &readFileCall{Path: path, Offset: 0, Length: 0},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if resp.ReadFile == nil {
+		return 0, fmt.Errorf("operator API v1 READ_FILE response missing read_file")
+	}
+	return int(resp.ReadFile.Size), nil
+}
+
+// downloadV1Ctx downloads an entire sandbox file via repeated READ_FILE
+// calls, the operator API v1 equivalent of downloadCtx's download.json fetch.
+func downloadV1Ctx(ctx context.Context, baseURL, path, filename string, progress ProgressReporter) (string, error) {
+	progress.Start(-1)
+	defer progress.Finish()
+
+	var buf bytes.Buffer
+	var file *os.File
+	if filename != "" {
+		if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+			return "", err
+		}
+		f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		file = f
+	}
+
+	offset := 0
+	for {
+		select {
+		case <-ctx.Done():
+			if filename != "" {
+				os.Remove(filename)
+			}
+			return "", ctx.Err()
+		default:
+		}
+
+		rd, err := readFileV1(baseURL, path, offset, PageLength)
+		if err != nil {
+			if filename != "" {
+				os.Remove(filename)
+			}
+			return "", err
+		}
+
+		if len(rd.Data) == 0 {
+			break
+		}
+
+		progress.Add(int64(len(rd.Data)))
+		if file != nil {
+			if _, err := file.WriteString(rd.Data); err != nil {
+				os.Remove(filename)
+				return "", err
+			}
+		} else {
+			buf.WriteString(rd.Data)
+		}
+
+		offset += len(rd.Data)
+		if len(rd.Data) < PageLength {
+			break
+		}
+	}
+
+	if filename != "" {
+		return filename, nil
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,268 @@
+package mesoslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// cacheBlockSize is the granularity at which sandbox file bytes are cached.
+	cacheBlockSize = 1 << 20 // 1 MiB
+
+	defaultCacheTotalBytes   = 1 << 30 // 1 GiB
+	defaultCachePerFileBytes = 100 << 20
+)
+
+// cacheKey identifies a single block of a sandbox file as served by a
+// specific slave.
+type cacheKey struct {
+	slaveHost string
+	path      string
+	block     int
+}
+
+type cachedBlock struct {
+	data []byte
+	// fileLength is the total sandbox file length, as reported by
+	// probeFileLength, at the time this block was fetched. Used to detect a task restart
+	// truncating the file out from under us.
+	fileLength int
+}
+
+// blockCache is an LRU cache of fixed-size sandbox file blocks, keyed by
+// (slaveHost, path, blockIndex). Concurrent misses for the same block are
+// serialized so only one HTTP request is issued per block.
+type blockCache struct {
+	cache        *lru.Cache
+	locks        sync.Map // cacheKey -> *sync.Mutex
+	perFileBytes int64
+
+	mu         sync.Mutex
+	fileBlocks map[string][]cacheKey // "slaveHost|path" -> blocks currently cached, oldest first
+}
+
+func newBlockCache(totalBytes, perFileBytes int64) (*blockCache, error) {
+	if totalBytes <= 0 {
+		totalBytes = defaultCacheTotalBytes
+	}
+	if perFileBytes <= 0 {
+		perFileBytes = defaultCachePerFileBytes
+	}
+
+	maxBlocks := int(totalBytes / cacheBlockSize)
+	if maxBlocks < 1 {
+		maxBlocks = 1
+	}
+
+	bc := &blockCache{
+		perFileBytes: perFileBytes,
+		fileBlocks:   make(map[string][]cacheKey),
+	}
+
+	c, err := lru.NewWithEvict(maxBlocks, bc.onEvict)
+	if err != nil {
+		return nil, err
+	}
+	bc.cache = c
+	return bc, nil
+}
+
+// onEvict runs whenever a block leaves bc.cache, whether trackFileBlock
+// evicted it for the per-file budget or the global LRU evicted it on its
+// own, keeping bc.locks and bc.fileBlocks from outliving the blocks they
+// describe.
+func (bc *blockCache) onEvict(key, _ interface{}) {
+	bc.locks.Delete(key)
+
+	ck := key.(cacheKey)
+	fk := fileKey(ck.slaveHost, ck.path)
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	blocks := bc.fileBlocks[fk]
+	for i, b := range blocks {
+		if b == ck {
+			bc.fileBlocks[fk] = append(blocks[:i], blocks[i+1:]...)
+			break
+		}
+	}
+}
+
+func fileKey(slaveHost, path string) string {
+	return fmt.Sprintf("%s|%s", slaveHost, path)
+}
+
+func (bc *blockCache) lockFor(key cacheKey) *sync.Mutex {
+	l, _ := bc.locks.LoadOrStore(key, &sync.Mutex{})
+	return l.(*sync.Mutex)
+}
+
+// getBlock returns the cached block if present and not stale relative to
+// currentFileLength, otherwise invokes fetch to populate it. fetch is only
+// called for genuine misses; a concurrent miss on the same key blocks on the
+// per-key lock instead of issuing a second request.
+func (bc *blockCache) getBlock(key cacheKey, currentFileLength int, fetch func() ([]byte, error)) ([]byte, error) {
+	lock := bc.lockFor(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if v, ok := bc.cache.Get(key); ok {
+		b := v.(*cachedBlock)
+		full := len(b.data) >= cacheBlockSize
+		switch {
+		case full && currentFileLength >= b.fileLength:
+			// a full block's bytes can't change short of the file
+			// shrinking (a task restart); growth elsewhere in the file
+			// doesn't touch this block.
+			return b.data, nil
+		case !full && currentFileLength == b.fileLength:
+			// a partial block is the tail of the file as of fileLength;
+			// it's only still accurate while the file hasn't grown (or
+			// shrunk) since.
+			return b.data, nil
+		}
+		bc.cache.Remove(key)
+	}
+
+	data, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	bc.cache.Add(key, &cachedBlock{data: data, fileLength: currentFileLength})
+	bc.trackFileBlock(key)
+	return data, nil
+}
+
+// trackFileBlock enforces the per-file byte budget by evicting the oldest
+// cached block of a file once it holds more blocks than the budget allows.
+// bc.cache.Remove is called outside bc.mu because it synchronously invokes
+// onEvict, which itself takes bc.mu.
+func (bc *blockCache) trackFileBlock(key cacheKey) {
+	bc.mu.Lock()
+	fk := fileKey(key.slaveHost, key.path)
+	blocks := append(bc.fileBlocks[fk], key)
+
+	maxBlocks := int(bc.perFileBytes / cacheBlockSize)
+	if maxBlocks < 1 {
+		maxBlocks = 1
+	}
+	var evict []cacheKey
+	for len(blocks) > maxBlocks {
+		evict = append(evict, blocks[0])
+		blocks = blocks[1:]
+	}
+	bc.fileBlocks[fk] = blocks
+	bc.mu.Unlock()
+
+	for _, k := range evict {
+		bc.cache.Remove(k)
+	}
+}
+
+// readRangeResult is the result of a single ranged read against a sandbox
+// file, used by both the legacy and operator API v1 fetch paths.
+type readRangeResult struct {
+	Data   string `json:"data"`
+	Offset int    `json:"offset"`
+}
+
+// probeFileLength asks the configured API - read.json or the operator API
+// v1 - for the current length of a sandbox file. Under the legacy API this
+// requests zero bytes from the file's end (offset -1 seeks to EOF) and reads
+// the length back from the echoed offset; v1 reports it directly.
+func probeFileLength(apiVersion, slaveHost, path string) (int, error) {
+	if apiVersion == APIVersionV1 {
+		return probeFileLengthV1(fmt.Sprintf("http://%s:5051", slaveHost), path)
+	}
+	url := fmt.Sprintf(TailURIFmt, slaveHost, path, -1, 0)
+	resp, err := download(url, "")
+	if err != nil {
+		return 0, err
+	}
+	var rd readRangeResult
+	if err := json.Unmarshal([]byte(resp), &rd); err != nil {
+		return 0, err
+	}
+	return rd.Offset, nil
+}
+
+// fetchBlock reads cacheBlockSize bytes starting at blockStart via the
+// configured API, for use as a blockCache.getBlock fetch func.
+func fetchBlock(apiVersion, slaveHost, path string, blockStart int) ([]byte, error) {
+	if apiVersion == APIVersionV1 {
+		rd, err := readFileV1(fmt.Sprintf("http://%s:5051", slaveHost), path, blockStart, cacheBlockSize)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(rd.Data), nil
+	}
+	url := fmt.Sprintf(TailURIFmt, slaveHost, path, blockStart, cacheBlockSize)
+	resp, err := download(url, "")
+	if err != nil {
+		return nil, err
+	}
+	var rd readRangeResult
+	if err := json.Unmarshal([]byte(resp), &rd); err != nil {
+		return nil, err
+	}
+	return []byte(rd.Data), nil
+}
+
+// readThrough reads [offset, offset+length) of a sandbox file on slaveHost,
+// stitching together cached and freshly-fetched 1 MiB blocks. It honors the
+// same offset/length semantics as TailURIFmt, fetching misses through
+// whichever API apiVersion selects.
+func (bc *blockCache) readThrough(apiVersion, slaveHost, path string, offset, length int) (string, error) {
+	if length <= 0 {
+		return "", nil
+	}
+
+	fileLength, err := probeFileLength(apiVersion, slaveHost, path)
+	if err != nil {
+		return "", err
+	}
+
+	start := offset
+	end := offset + length
+	if end > fileLength {
+		end = fileLength
+	}
+
+	var out []byte
+	for blockStart := (start / cacheBlockSize) * cacheBlockSize; blockStart < end; blockStart += cacheBlockSize {
+		key := cacheKey{slaveHost: slaveHost, path: path, block: blockStart / cacheBlockSize}
+
+		block, err := bc.getBlock(key, fileLength, func() ([]byte, error) {
+			return fetchBlock(apiVersion, slaveHost, path, blockStart)
+		})
+		if err != nil {
+			return "", err
+		}
+
+		blockEnd := blockStart + len(block)
+		lo, hi := maxInt(start, blockStart), minInt(end, blockEnd)
+		if lo >= hi {
+			break
+		}
+		out = append(out, block[lo-blockStart:hi-blockStart]...)
+	}
+	return string(out), nil
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
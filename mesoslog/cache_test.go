@@ -0,0 +1,81 @@
+package mesoslog
+
+import "testing"
+
+// TestBlockCacheEvictionPrunesBookkeeping is a regression test for two bugs:
+// bc.locks growing forever because no eviction ever pruned it, and
+// bc.fileBlocks going stale when the global LRU evicted a block on its own
+// (rather than through trackFileBlock). Both are driven by onEvict, so a
+// cache sized to hold a single block should never accumulate more than one
+// entry in either.
+func TestBlockCacheEvictionPrunesBookkeeping(t *testing.T) {
+	bc, err := newBlockCache(cacheBlockSize, cacheBlockSize)
+	if err != nil {
+		t.Fatalf("newBlockCache: %v", err)
+	}
+
+	fetch := func(n int) func() ([]byte, error) {
+		return func() ([]byte, error) { return []byte{byte(n)}, nil }
+	}
+
+	for i := 0; i < 5; i++ {
+		key := cacheKey{slaveHost: "slave", path: "stdout", block: i}
+		if _, err := bc.getBlock(key, 0, fetch(i)); err != nil {
+			t.Fatalf("getBlock(%d): %v", i, err)
+		}
+	}
+
+	locksLeft := 0
+	bc.locks.Range(func(key, value interface{}) bool {
+		locksLeft++
+		return true
+	})
+	if locksLeft != 1 {
+		t.Errorf("bc.locks has %d entries after 4 evictions from a 1-block cache, want 1", locksLeft)
+	}
+
+	if got := len(bc.fileBlocks[fileKey("slave", "stdout")]); got != 1 {
+		t.Errorf("bc.fileBlocks tracks %d blocks after 4 evictions from a 1-block cache, want 1", got)
+	}
+}
+
+// TestBlockCacheGetBlockRefetchesGrownPartialBlock is a regression test for a
+// staleness check that only caught a shrunk file, not a file that grew
+// within its one-and-only (partial) block - the common case for any sandbox
+// file under 1 MiB. A cached partial block must be refetched once
+// currentFileLength no longer matches the length it was cached at, whether
+// that length went up or down.
+func TestBlockCacheGetBlockRefetchesGrownPartialBlock(t *testing.T) {
+	bc, err := newBlockCache(defaultCacheTotalBytes, defaultCachePerFileBytes)
+	if err != nil {
+		t.Fatalf("newBlockCache: %v", err)
+	}
+
+	key := cacheKey{slaveHost: "slave", path: "stdout", block: 0}
+	fetches := 0
+	fetch := func(data string) func() ([]byte, error) {
+		return func() ([]byte, error) {
+			fetches++
+			return []byte(data), nil
+		}
+	}
+
+	data, err := bc.getBlock(key, 200, fetch(string(make([]byte, 200))))
+	if err != nil {
+		t.Fatalf("getBlock (initial): %v", err)
+	}
+	if len(data) != 200 || fetches != 1 {
+		t.Fatalf("initial getBlock: got %d bytes, %d fetches, want 200 bytes, 1 fetch", len(data), fetches)
+	}
+
+	data, err = bc.getBlock(key, 400, fetch(string(make([]byte, 400))))
+	if err != nil {
+		t.Fatalf("getBlock (grown): %v", err)
+	}
+	if len(data) != 400 {
+		t.Errorf("getBlock after growth returned %d bytes, want 400 (the grown block refetched)", len(data))
+	}
+	if fetches != 2 {
+		t.Errorf("getBlock after growth issued %d fetches, want 2 (stale partial block must be refetched)", fetches)
+	}
+}
@@ -4,7 +4,7 @@ package mesoslog
 // where the stdout and stderr streams for a running task can be viewed
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,7 +15,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -29,14 +29,48 @@ const (
 type MesosClient struct {
 	Host      string
 	Port      int
-	MasterURL string
+	MasterURL string // leading master as of construction or the last RefreshState, which picks up a failover seen by the detector
 	State     *masterState
 	Options   *MesosClientOptions
+
+	logBytesRead int64
+	cache        *blockCache
+	detector     *masterDetector
+	hub          *tailHub
 }
 
 type MesosClientOptions struct {
 	SearchCompletedTasks bool
 	ShowLatestOnly       bool
+
+	// ScrapeConcurrency bounds how many slaves the exporter fans out to at
+	// once when collecting metrics. Defaults to 10 when <= 0.
+	ScrapeConcurrency int
+
+	// ScrapeInterval controls how often the exporter refreshes master and
+	// slave state. Defaults to 15s when <= 0.
+	ScrapeInterval time.Duration
+
+	// EnableCache turns on the block cache in front of GetLog/TailLog reads
+	// so repeated calls against the same task don't re-fetch the whole file.
+	EnableCache bool
+
+	// CacheTotalBytes caps the overall size of the block cache. Defaults to
+	// 1 GiB when <= 0.
+	CacheTotalBytes int64
+
+	// CachePerFileBytes caps how much of the cache a single sandbox file may
+	// occupy. Defaults to 100 MiB when <= 0.
+	CachePerFileBytes int64
+
+	// APIVersion selects the Mesos HTTP API used to talk to the master and
+	// slaves: APIVersionLegacy (the default) for the 0.x state.json/files
+	// endpoints, or APIVersionV1 for the versioned operator API.
+	APIVersion string
+
+	// Logger receives structured Debug/Info/Error messages from background
+	// tailing. Defaults to a stderr logger at LevelInfo when nil.
+	Logger Logger
 }
 
 // NewMesosClient - Creates a new MesosClient
@@ -51,26 +85,112 @@ func NewMesosClient(host string, port int) (*MesosClient, error) {
 // {port} - the port # of the mesos master node
 // {options} - client options - optional
 func NewMesosClientWithOptions(host string, port int, options *MesosClientOptions) (*MesosClient, error) {
-	masterURL, err := getMasterRedirect(host, port)
-	if err != nil {
-		return nil, err
-	}
-
 	if options == nil {
 		options = &MesosClientOptions{}
 	}
 
-	state, err := getMasterState(masterURL)
+	detector := newMasterDetector(host, port)
+	go detector.Start()
+
+	select {
+	case <-detector.Ready():
+	case <-time.After(30 * time.Second):
+		detector.Stop()
+		return nil, fmt.Errorf("could not detect a leading master for %s:%d", host, port)
+	}
+	masterURL := detector.Current()
+
+	state, err := fetchMasterState(masterURL, options.APIVersion)
 	if err != nil {
+		detector.Stop()
 		return nil, err
 	}
-	return &MesosClient{
+
+	client := &MesosClient{
 		Host:      host,
 		Port:      port,
 		MasterURL: masterURL,
 		State:     state,
 		Options:   options,
-	}, nil
+		detector:  detector,
+		hub:       newTailHub(),
+	}
+
+	if options.EnableCache {
+		cache, err := newBlockCache(options.CacheTotalBytes, options.CachePerFileBytes)
+		if err != nil {
+			return nil, err
+		}
+		client.cache = cache
+	}
+
+	return client, nil
+}
+
+// RefreshState - Re-fetches master state and replaces c.State with it,
+// following the detector to the current leading master if it has changed.
+func (c *MesosClient) RefreshState() error {
+	if c.detector != nil {
+		if cur := c.detector.Current(); cur != "" {
+			c.MasterURL = cur
+		}
+	}
+
+	state, err := fetchMasterState(c.MasterURL, c.Options.APIVersion)
+	if err != nil {
+		return err
+	}
+	c.State = state
+	return nil
+}
+
+// Close stops the background master-failover detector.
+func (c *MesosClient) Close() {
+	if c.detector != nil {
+		c.detector.Stop()
+	}
+}
+
+// fetchMasterState fetches master state using the 0.x state.json endpoint or
+// the versioned operator API, per apiVersion.
+func fetchMasterState(masterURL, apiVersion string) (*masterState, error) {
+	if apiVersion == APIVersionV1 {
+		return getMasterStateV1(masterURL)
+	}
+	return getMasterState(masterURL)
+}
+
+// fetchSlaveState fetches a slave's state using the 0.x state.json endpoint
+// or the versioned operator API, per apiVersion.
+func fetchSlaveState(slaveURL *url.URL, apiVersion string) (*slaveState, error) {
+	if apiVersion == APIVersionV1 {
+		return getSlaveStateV1(fmt.Sprintf("%s://%s", slaveURL.Scheme, slaveURL.Host))
+	}
+	return getSlaveState(slaveURL)
+}
+
+// readCachedFile reads the full contents of a sandbox file through the block
+// cache. Only used when Options.EnableCache is set.
+func (c *MesosClient) readCachedFile(slaveHost, path string) (string, error) {
+	length, err := probeFileLength(c.Options.APIVersion, slaveHost, path)
+	if err != nil {
+		return "", err
+	}
+	return c.cache.readThrough(c.Options.APIVersion, slaveHost, path, 0, length)
+}
+
+// logger returns the client's configured Logger, falling back to a
+// package-wide default when Options.Logger is unset.
+func (c *MesosClient) logger() Logger {
+	if c.Options != nil && c.Options.Logger != nil {
+		return c.Options.Logger
+	}
+	return defaultLogger
+}
+
+// LogBytesRead - total bytes read across all tail streams opened by this client
+func (c *MesosClient) LogBytesRead() int64 {
+	return atomic.LoadInt64(&c.logBytesRead)
 }
 
 // GetAppNames - List all unique app names aka task names running in the Mesos cluster
@@ -83,10 +203,17 @@ func (c *MesosClient) GetAppNames() (map[string]int, error) {
 }
 
 // GetLog - Gets/Downloads logs for a [appID]
+// {ctx} - cancels the download(s) in progress; canceling removes any partial
+//         output file rather than leaving it behind
 // {appID} - the task name / app identifier
 // {logtype} - the desired log type STDOUT | STDERR
 // {dir} - optional output dir which is used to download vs stdout
-func (c *MesosClient) GetLog(appID string, logtype LogType, dir string) ([]*LogOut, error) {
+// {progress} - optional reporter for download throughput; pass NoProgress if not needed
+func (c *MesosClient) GetLog(ctx context.Context, appID string, logtype LogType, dir string, progress ProgressReporter) ([]*LogOut, error) {
+	if progress == nil {
+		progress = NoProgress
+	}
+
 	var result []*LogOut
 
 	taskInfo := findTask(c.State, appID)
@@ -106,18 +233,34 @@ func (c *MesosClient) GetLog(appID string, logtype LogType, dir string) ([]*LogO
 	}
 
 	for _, task := range tasks {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
 		slaveInfo, err := c.getSlaveInfo(task)
 		if err != nil {
 			return nil, err
 		}
 
-		url := fmt.Sprintf("http://%s:5051/files/download.json?path=%s/", slaveInfo.Slave.Hostname, slaveInfo.Directory)
-
 		var filename string
 		if dir != "" {
 			filename = filepath.Join(dir, fmt.Sprintf("%s_%s.txt", task.ID, logtype.String()))
 		}
-		data, err := download(url+logtype.String(), filename)
+
+		path := fmt.Sprintf("%s/%s", slaveInfo.Directory, logtype.String())
+
+		var data string
+		switch {
+		case c.cache != nil && dir == "":
+			data, err = c.readCachedFile(slaveInfo.Slave.Hostname, path)
+		case c.Options.APIVersion == APIVersionV1:
+			data, err = downloadV1Ctx(ctx, fmt.Sprintf("http://%s:5051", slaveInfo.Slave.Hostname), path, filename, progress)
+		default:
+			url := fmt.Sprintf("http://%s:5051/files/download.json?path=%s/", slaveInfo.Slave.Hostname, slaveInfo.Directory)
+			data, err = downloadCtx(ctx, url+logtype.String(), filename, progress)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -126,48 +269,54 @@ func (c *MesosClient) GetLog(appID string, logtype LogType, dir string) ([]*LogO
 	return result, nil
 }
 
-func (c *MesosClient) TailLogToChannel(appID string, logtype LogType, duration int, target chan<- string, done chan bool) error {
+// TailLogToChannel - Tails the logs for a [appID], delivering a typed
+// LogEvent per line rather than a pre-formatted string. Multiple calls
+// tailing the same task/slave/path share one poller via c.hub, so repeated
+// subscribers don't multiply HTTP traffic to the slave.
+func (c *MesosClient) TailLogToChannel(appID string, logtype LogType, duration int, target chan<- LogEvent, done chan bool) error {
+	// Closed on every return path, including the early-error ones, so a
+	// caller ranging over target always sees it terminate.
+	defer close(target)
+
 	tasks := findTask(c.State, appID).Tasks
 
 	if tasks == nil || len(tasks) == 0 {
 		return fmt.Errorf("application could not be found")
 	}
 
-	var chans []<-chan string
+	var unsubscribe []func()
 	for _, task := range tasks {
-
 		slaveInfo, err := c.getSlaveInfo(task)
 		if err != nil {
+			for _, u := range unsubscribe {
+				u()
+			}
 			return err
 		}
-		o := c.asyncTail(task, slaveInfo, logtype, duration)
-		chans = append(chans, o)
-
+		unsubscribe = append(unsubscribe, c.hub.subscribe(c, task, slaveInfo, logtype, appID, duration, target))
 	}
-	output := merge(chans...)
-	for {
-		select {
-		case msg := <-output:
-			target <- msg
-		case <-done:
-			return nil
-		}
+
+	<-done
+	for _, u := range unsubscribe {
+		u()
 	}
+	return nil
 }
 
-// TailLog - Tails the logs for a [appID]
+// TailLog - Tails the logs for a [appID], formatting each line the way the
+// CLI has always displayed it.
 // {appID} - the task name / app identifier
 // {logtype} - the desired log type STDOUT | STDERR
 // {duration} - poll frequency in seconds
 func (c *MesosClient) TailLog(appID string, logtype LogType, duration int) error {
 
-	target := make(chan string)
+	target := make(chan LogEvent)
 	done := make(chan bool)
 
 	go c.TailLogToChannel(appID, logtype, duration, target, done)
 
-	for msg := range target {
-		fmt.Println(msg)
+	for e := range target {
+		fmt.Println(FormatLogEvent(e))
 	}
 
 	return nil
@@ -210,72 +359,86 @@ func reversePath(s []string) []string {
 	return s
 }
 
-func (c *MesosClient) asyncTail(task *mstateTask, s *slaveInfo, lt LogType, duration int) <-chan string {
-	ch := make(chan string)
-	path := fmt.Sprintf("%s/%s", s.Directory, lt.String())
-	go func() {
-		offset := 0
-		for {
-			url := fmt.Sprintf(TailURIFmt, s.Slave.Hostname, path, offset, PageLength)
-
-			resp, err := download(url, "")
-			if err != nil {
-				fmt.Printf("Error: %s", err.Error())
-				continue
-			}
-			var rd readData
-			json.Unmarshal([]byte(resp), &rd)
-
-			if len(rd.Data) < 5 {
-				time.Sleep(time.Duration(duration) * time.Second)
-				continue
-			}
-			offset += len(rd.Data)
-			ch <- decorateLog(task.ID, rd.Data)
+// readTailChunk reads [offset, offset+length) of a task's log file on a
+// slave, via the block cache, operator API v1, or legacy read.json.
+func (c *MesosClient) readTailChunk(slaveHost, path string, offset, length int) (string, error) {
+	switch {
+	case c.cache != nil:
+		return c.cache.readThrough(c.Options.APIVersion, slaveHost, path, offset, length)
+	case c.Options.APIVersion == APIVersionV1:
+		rd, err := readFileV1(fmt.Sprintf("http://%s:5051", slaveHost), path, offset, length)
+		return rd.Data, err
+	default:
+		url := fmt.Sprintf(TailURIFmt, slaveHost, path, offset, length)
+		resp, err := download(url, "")
+		if err != nil {
+			return "", err
 		}
-	}()
-	return ch
+		var rd readData
+		json.Unmarshal([]byte(resp), &rd)
+		return rd.Data, nil
+	}
 }
 
-func merge(cs ...<-chan string) <-chan string {
-	var wg sync.WaitGroup
-	out := make(chan string)
+// TaskStateCount - number of tasks sharing a framework, app name, completion
+// status and last-known Mesos task state.
+type TaskStateCount struct {
+	FrameworkID string
+	AppName     string
+	State       string
+	Completed   bool
+	Count       int
+}
 
-	// Start an output goroutine for each input channel in cs.  output
-	// copies values from c to out until c is closed, then calls wg.Done.
-	output := func(c <-chan string) {
-		for n := range c {
-			out <- n
-		}
-		wg.Done()
+// TaskStateCounts - tallies every task/completed-task in the current state
+// snapshot by (framework, app name, state, completed).
+func (c *MesosClient) TaskStateCounts() []TaskStateCount {
+	counts := make(map[TaskStateCount]int)
+	for _, framework := range c.State.Frameworks {
+		tallyTaskStates(framework.Tasks, framework.ID, false, counts)
+		tallyTaskStates(framework.CompletedTasks, framework.ID, true, counts)
 	}
-	wg.Add(len(cs))
-	for _, c := range cs {
-		go output(c)
+
+	result := make([]TaskStateCount, 0, len(counts))
+	for k, n := range counts {
+		k.Count = n
+		result = append(result, k)
 	}
+	return result
+}
 
-	// Start a goroutine to close out once all the output goroutines are
-	// done.  This must start after the wg.Add call.
-	go func() {
-		wg.Wait()
-		close(out)
-	}()
-	return out
-}
-
-func decorateLog(name, data string) string {
-	lines := strings.Split(data, "\n")
-	buf := new(bytes.Buffer)
-	taskIdx := strings.Index(name, ".")
-	dec := name[0:taskIdx]
-	taskId := name[taskIdx+1:]
-	dec = fmt.Sprintf("%s.%s", dec, taskId[0:strings.Index(taskId, "-")])
-	for _, l := range lines {
-		if len(l) > 0 {
-			buf.WriteString(fmt.Sprintf("[%s] %s\n", dec, l))
+func tallyTaskStates(tasks []*mstateTask, frameworkID string, completed bool, counts map[TaskStateCount]int) {
+	for _, task := range tasks {
+		state := "UNKNOWN"
+		if ts := findTaskLastState(task); ts != nil {
+			state = ts.State
 		}
+		key := TaskStateCount{FrameworkID: frameworkID, AppName: task.Name, State: state, Completed: completed}
+		counts[key]++
+	}
+}
+
+// SlaveHosts - hostnames of every slave in the current master state snapshot
+func (c *MesosClient) SlaveHosts() []string {
+	hosts := make([]string, 0, len(c.State.Slaves))
+	for _, s := range c.State.Slaves {
+		hosts = append(hosts, s.Hostname)
+	}
+	return hosts
+}
+
+// ProbeSlave - issues a lightweight state.json request against a slave host
+// and reports whether it answered plus how long it took.
+func (c *MesosClient) ProbeSlave(host string) (reachable bool, latency time.Duration, err error) {
+	url := fmt.Sprintf("http://%s:5051/state.json", host)
+	start := time.Now()
+	resp, err := http.Get(url)
+	latency = time.Since(start)
+	if err != nil {
+		return false, latency, err
 	}
-	return buf.String()
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK, latency, nil
 }
 
 func (c *MesosClient) getSlaveInfo(task *mstateTask) (*slaveInfo, error) {
@@ -290,7 +453,7 @@ func (c *MesosClient) getSlaveInfo(task *mstateTask) (*slaveInfo, error) {
 		return nil, err
 	}
 
-	slaveState, err := getSlaveState(slaveURL)
+	slaveState, err := fetchSlaveState(slaveURL, c.Options.APIVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -303,9 +466,9 @@ func (c *MesosClient) getSlaveInfo(task *mstateTask) (*slaveInfo, error) {
 	return &slaveInfo{Slave: slave, State: slaveState, Directory: directory}, nil
 }
 
-func getMasterRedirect(host string, port int) (string, error) {
+func getMasterRedirect(ctx context.Context, host string, port int) (string, error) {
 	url := fmt.Sprintf("http://%s:%d/master/redirect", host, port)
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return "", err
 	}
@@ -481,6 +644,58 @@ func download(slaveURL string, filename string) (string, error) {
 
 }
 
+// downloadCtx is the context-aware, progress-reporting counterpart to download.
+func downloadCtx(ctx context.Context, slaveURL string, filename string, progress ProgressReporter) (string, error) {
+	req, err := http.NewRequest("GET", slaveURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	progress.Start(resp.ContentLength)
+	reader := &countingReader{r: resp.Body, progress: progress}
+
+	// Closing the body unblocks any in-flight Read once ctx is canceled.
+	aborted := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			resp.Body.Close()
+		case <-aborted:
+		}
+	}()
+	defer close(aborted)
+
+	if filename != "" {
+		werr := writeFile(filename, reader)
+		progress.Finish()
+		if ctx.Err() != nil {
+			os.Remove(filename)
+			return "", ctx.Err()
+		}
+		if werr != nil {
+			return "", werr
+		}
+		return filename, nil
+	}
+
+	data, rerr := ioutil.ReadAll(reader)
+	progress.Finish()
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if rerr != nil {
+		return "", rerr
+	}
+	return string(data), nil
+}
+
 func writeFile(filename string, r io.Reader) error {
 	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
 		return err
@@ -0,0 +1,107 @@
+package mesoslog
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// masterDetector watches /master/redirect in the background and keeps track
+// of the currently leading master, so a MesosClient can transparently follow
+// failovers instead of pinning MasterURL at construction time.
+type masterDetector struct {
+	host string
+	port int
+
+	mu      sync.RWMutex
+	current string
+
+	ready     chan struct{}
+	readyOnce sync.Once
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+
+	pollInterval time.Duration
+}
+
+func newMasterDetector(host string, port int) *masterDetector {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &masterDetector{
+		host:         host,
+		port:         port,
+		ready:        make(chan struct{}),
+		ctx:          ctx,
+		cancel:       cancel,
+		pollInterval: 10 * time.Second,
+	}
+}
+
+// Ready closes once the first leading master has been resolved.
+func (d *masterDetector) Ready() <-chan struct{} {
+	return d.ready
+}
+
+// Current returns the most recently detected leading master URL. Empty
+// until Ready() has closed.
+func (d *masterDetector) Current() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.current
+}
+
+// Start runs the detection loop until Stop is called. It backs off on
+// lookup failures (redirect errors, 503s while the cluster elects a new
+// leader) and resets to fast retries the moment a leader is found, so a
+// failover is noticed quickly without hammering the cluster while one is
+// underway.
+func (d *masterDetector) Start() {
+	backoff := time.Second
+	for {
+		master, err := getMasterRedirect(d.ctx, d.host, d.port)
+		if err != nil {
+			if d.ctx.Err() != nil {
+				return
+			}
+			if !d.sleep(backoff) {
+				return
+			}
+			if backoff < d.pollInterval {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = time.Second
+
+		d.mu.Lock()
+		changed := d.current != master
+		d.current = master
+		d.mu.Unlock()
+
+		if changed {
+			d.readyOnce.Do(func() { close(d.ready) })
+		}
+
+		if !d.sleep(d.pollInterval) {
+			return
+		}
+	}
+}
+
+// sleep waits out d2 or returns false immediately if Stop is called first.
+func (d *masterDetector) sleep(d2 time.Duration) bool {
+	select {
+	case <-time.After(d2):
+		return true
+	case <-d.ctx.Done():
+		return false
+	}
+}
+
+// Stop halts the detection loop started by Start, canceling any in-flight
+// request to the master rather than leaving it to run to completion. Safe
+// to call more than once.
+func (d *masterDetector) Stop() {
+	d.stopOnce.Do(d.cancel)
+}
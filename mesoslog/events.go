@@ -0,0 +1,15 @@
+package mesoslog
+
+import "time"
+
+// LogEvent is a single decoded line from a task's sandbox log stream,
+// delivered by TailLogToChannel in place of the pre-formatted strings it
+// used to send.
+type LogEvent struct {
+	TaskID    string
+	AppID     string
+	Stream    LogType
+	Offset    int
+	Line      string
+	Timestamp time.Time
+}
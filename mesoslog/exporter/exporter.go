@@ -0,0 +1,169 @@
+// Package exporter exposes cluster and log-tailing statistics collected via
+// a mesoslog.MesosClient as Prometheus metrics.
+package exporter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/containx/go-mesoslog/mesoslog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultScrapeConcurrency = 10
+	defaultScrapeInterval    = 15 * time.Second
+	namespace                = "mesoslog"
+)
+
+// Exporter periodically scrapes a MesosClient's master/slave state and log
+// tail byte counters and serves them as Prometheus metrics.
+type Exporter struct {
+	client      *mesoslog.MesosClient
+	concurrency int
+	interval    time.Duration
+	stop        chan struct{}
+
+	tasks        *prometheus.GaugeVec
+	slaveUp      *prometheus.GaugeVec
+	slaveLatency *prometheus.GaugeVec
+	logBytes     prometheus.Gauge
+
+	mu sync.Mutex
+}
+
+// New creates an Exporter for the given client. Scrape concurrency and
+// interval are taken from client.Options when set, falling back to sane
+// defaults otherwise.
+func New(client *mesoslog.MesosClient) *Exporter {
+	concurrency := defaultScrapeConcurrency
+	interval := defaultScrapeInterval
+	if opts := client.Options; opts != nil {
+		if opts.ScrapeConcurrency > 0 {
+			concurrency = opts.ScrapeConcurrency
+		}
+		if opts.ScrapeInterval > 0 {
+			interval = opts.ScrapeInterval
+		}
+	}
+
+	return &Exporter{
+		client:      client,
+		concurrency: concurrency,
+		interval:    interval,
+		stop:        make(chan struct{}),
+		tasks: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "tasks",
+			Help:      "Number of Mesos tasks by framework, app name, state and completion status",
+		}, []string{"framework_id", "app", "state", "completed"}),
+		slaveUp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "slave_up",
+			Help:      "Whether a slave answered its state.json probe (1) or not (0)",
+		}, []string{"slave"}),
+		slaveLatency: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "slave_probe_latency_seconds",
+			Help:      "Latency of the state.json reachability probe against a slave",
+		}, []string{"slave"}),
+		logBytes: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "log_bytes_read_total",
+			Help:      "Total bytes read across all log tail streams opened by this client",
+		}),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	e.tasks.Describe(ch)
+	e.slaveUp.Describe(ch)
+	e.slaveLatency.Describe(ch)
+	e.logBytes.Describe(ch)
+}
+
+// Collect implements prometheus.Collector. It serves the most recently
+// scraped values; Start runs the periodic scrape loop that keeps them fresh.
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.tasks.Collect(ch)
+	e.slaveUp.Collect(ch)
+	e.slaveLatency.Collect(ch)
+	e.logBytes.Collect(ch)
+}
+
+// Start begins the periodic scrape loop. It blocks until Stop is called.
+func (e *Exporter) Start() {
+	e.scrape()
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.scrape()
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// Stop halts the periodic scrape loop started by Start.
+func (e *Exporter) Stop() {
+	close(e.stop)
+}
+
+func (e *Exporter) scrape() {
+	if err := e.client.RefreshState(); err != nil {
+		return
+	}
+
+	e.mu.Lock()
+	e.tasks.Reset()
+	for _, tc := range e.client.TaskStateCounts() {
+		completed := "false"
+		if tc.Completed {
+			completed = "true"
+		}
+		e.tasks.WithLabelValues(tc.FrameworkID, tc.AppName, tc.State, completed).Set(float64(tc.Count))
+	}
+	e.logBytes.Set(float64(e.client.LogBytesRead()))
+	e.mu.Unlock()
+
+	e.probeSlaves()
+}
+
+// probeSlaves fans out state.json reachability checks across a bounded
+// worker pool so a single unreachable slave can't stall the scrape.
+func (e *Exporter) probeSlaves() {
+	hosts := e.client.SlaveHosts()
+	hostCh := make(chan string)
+
+	var wg sync.WaitGroup
+	for i := 0; i < e.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range hostCh {
+				up, latency, err := e.client.ProbeSlave(host)
+				upValue := 0.0
+				if up && err == nil {
+					upValue = 1.0
+				}
+
+				e.mu.Lock()
+				e.slaveUp.WithLabelValues(host).Set(upValue)
+				e.slaveLatency.WithLabelValues(host).Set(latency.Seconds())
+				e.mu.Unlock()
+			}
+		}()
+	}
+
+	for _, host := range hosts {
+		hostCh <- host
+	}
+	close(hostCh)
+	wg.Wait()
+}
@@ -0,0 +1,29 @@
+package mesoslog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FormatLogEvent renders a LogEvent the way this package has always
+// displayed tailed log lines: "[app.shortTaskID] line". It's the formatter
+// subscriber TailLog wraps around a raw LogEvent channel; CLI callers that
+// want the historical plain-text output can use it the same way.
+func FormatLogEvent(e LogEvent) string {
+	return fmt.Sprintf("[%s] %s", decorateTag(e.TaskID), e.Line)
+}
+
+// decorateTag derives the "app.shortID" display tag from a Mesos task ID
+// shaped like "app.uuid-timestamp".
+func decorateTag(taskID string) string {
+	taskIdx := strings.Index(taskID, ".")
+	if taskIdx < 0 {
+		return taskID
+	}
+	dec := taskID[0:taskIdx]
+	rest := taskID[taskIdx+1:]
+	if dashIdx := strings.Index(rest, "-"); dashIdx >= 0 {
+		rest = rest[0:dashIdx]
+	}
+	return fmt.Sprintf("%s.%s", dec, rest)
+}
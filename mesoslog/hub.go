@@ -0,0 +1,156 @@
+package mesoslog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tailHub deduplicates HTTP tailing traffic: multiple subscribers tailing
+// the same (slave, path) share a single poller against the slave, with
+// decoded lines multicast to every current subscriber.
+type tailHub struct {
+	mu      sync.Mutex
+	streams map[string]*tailStream
+}
+
+func newTailHub() *tailHub {
+	return &tailHub{streams: make(map[string]*tailStream)}
+}
+
+type tailStream struct {
+	// mu guards subscribers/nextID. publish only needs a read lock to take
+	// its snapshot; subscribe/unsubscribe take the write lock, so a
+	// subscriber removed by unsubscribe can never appear in a publish
+	// snapshot taken after unsubscribe returns.
+	mu          sync.RWMutex
+	subscribers map[int]chan<- LogEvent
+	nextID      int
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// subscribe attaches target to the tail of (task, logtype) on slave s,
+// starting the underlying poller on the first subscriber and tearing it
+// down once the last subscriber unsubscribes. The returned func
+// unsubscribes target.
+func (h *tailHub) subscribe(c *MesosClient, task *mstateTask, s *slaveInfo, lt LogType, appID string, duration int, target chan<- LogEvent) func() {
+	path := fmt.Sprintf("%s/%s", s.Directory, lt.String())
+	key := fileKey(s.Slave.Hostname, path)
+
+	h.mu.Lock()
+	stream, ok := h.streams[key]
+	if !ok {
+		stream = &tailStream{subscribers: make(map[int]chan<- LogEvent), stop: make(chan struct{})}
+		h.streams[key] = stream
+		go stream.poll(c, task, s, lt, appID, duration, path)
+	}
+	h.mu.Unlock()
+
+	stream.mu.Lock()
+	id := stream.nextID
+	stream.nextID++
+	stream.subscribers[id] = target
+	stream.mu.Unlock()
+
+	return func() {
+		stream.mu.Lock()
+		delete(stream.subscribers, id)
+		empty := len(stream.subscribers) == 0
+		stream.mu.Unlock()
+
+		if !empty {
+			return
+		}
+
+		h.mu.Lock()
+		if h.streams[key] == stream {
+			delete(h.streams, key)
+		}
+		h.mu.Unlock()
+		stream.stopOnce.Do(func() { close(stream.stop) })
+	}
+}
+
+// publish multicasts e to every current subscriber. Sends are non-blocking:
+// a subscriber that isn't keeping up has its line dropped rather than
+// wedging delivery for every other subscriber of this stream.
+func (s *tailStream) publish(log Logger, e LogEvent) {
+	s.mu.RLock()
+	targets := make([]chan<- LogEvent, 0, len(s.subscribers))
+	for _, target := range s.subscribers {
+		targets = append(targets, target)
+	}
+	s.mu.RUnlock()
+
+	for _, target := range targets {
+		select {
+		case target <- e:
+		default:
+			log.Errorf("tail subscriber for %s is not keeping up, dropping line", e.AppID)
+		}
+	}
+}
+
+// poll reads path in PageLength chunks and multicasts each non-empty line to
+// every current subscriber, until the last subscriber unsubscribes.
+func (s *tailStream) poll(c *MesosClient, task *mstateTask, slave *slaveInfo, lt LogType, appID string, duration int, path string) {
+	log := c.logger()
+	offset := 0
+	for {
+		data, err := c.readTailChunk(slave.Slave.Hostname, path, offset, PageLength)
+		if err != nil {
+			log.Errorf("tail %s on %s: %s", path, slave.Slave.Hostname, err.Error())
+			if !s.sleep(duration) {
+				return
+			}
+			continue
+		}
+
+		if len(data) < 5 {
+			if !s.sleep(duration) {
+				return
+			}
+			continue
+		}
+
+		offset += len(data)
+		atomic.AddInt64(&c.logBytesRead, int64(len(data)))
+		log.Debugf("read %d bytes from %s on %s", len(data), path, slave.Slave.Hostname)
+
+		now := time.Now()
+		for _, line := range strings.Split(data, "\n") {
+			if len(line) == 0 {
+				continue
+			}
+			s.publish(log, LogEvent{
+				TaskID:    task.ID,
+				AppID:     appID,
+				Stream:    lt,
+				Offset:    offset,
+				Line:      line,
+				Timestamp: now,
+			})
+		}
+
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+	}
+}
+
+// sleep waits out duration seconds, or returns false immediately if the
+// stream is stopped first.
+func (s *tailStream) sleep(duration int) bool {
+	select {
+	case <-time.After(time.Duration(duration) * time.Second):
+		return true
+	case <-s.stop:
+		return false
+	}
+}
@@ -0,0 +1,59 @@
+package mesoslog
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestTailStreamPublishUnsubscribeConcurrent is a regression test for a
+// sync.WaitGroup previously reused as a publish/unsubscribe shutdown
+// barrier: a publish landing between a Done that zeroed the counter and a
+// new Add, or concurrent unsubscribes both calling Wait, could panic with
+// "sync: WaitGroup misuse". Run with -race to also catch any remaining data
+// race on the subscriber map.
+func TestTailStreamPublishUnsubscribeConcurrent(t *testing.T) {
+	stream := &tailStream{subscribers: make(map[int]chan<- LogEvent), stop: make(chan struct{})}
+	log := NewStdLogger(LevelError)
+
+	const subscribers = 20
+	ids := make([]int, subscribers)
+	for i := range ids {
+		target := make(chan LogEvent, 1)
+		stream.mu.Lock()
+		id := stream.nextID
+		stream.nextID++
+		stream.subscribers[id] = target
+		stream.mu.Unlock()
+		ids[i] = id
+	}
+
+	stopPublish := make(chan struct{})
+	publisherDone := make(chan struct{})
+	go func() {
+		defer close(publisherDone)
+		for {
+			select {
+			case <-stopPublish:
+				return
+			default:
+				stream.publish(log, LogEvent{AppID: "app"})
+			}
+		}
+	}()
+
+	var unsubscribers sync.WaitGroup
+	unsubscribers.Add(len(ids))
+	for _, id := range ids {
+		id := id
+		go func() {
+			defer unsubscribers.Done()
+			stream.mu.Lock()
+			delete(stream.subscribers, id)
+			stream.mu.Unlock()
+		}()
+	}
+	unsubscribers.Wait()
+
+	close(stopPublish)
+	<-publisherDone
+}
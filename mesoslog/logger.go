@@ -0,0 +1,50 @@
+package mesoslog
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Level is a logging severity, ordered least to most severe.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelError
+)
+
+// Logger is the structured-logging interface used by background tailing.
+// Pass a custom implementation (e.g. a logrus adapter) via
+// MesosClientOptions.Logger; the default writes level-prefixed lines to
+// stderr.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+var defaultLogger = NewStdLogger(LevelInfo)
+
+// stdLogger is the default Logger: stderr output, filtered by Level.
+type stdLogger struct {
+	level Level
+}
+
+// NewStdLogger returns a Logger that writes level-prefixed lines to stderr,
+// discarding anything below level.
+func NewStdLogger(level Level) Logger {
+	return &stdLogger{level: level}
+}
+
+func (l *stdLogger) Debugf(format string, args ...interface{}) { l.logf(LevelDebug, "DEBUG", format, args...) }
+func (l *stdLogger) Infof(format string, args ...interface{})  { l.logf(LevelInfo, "INFO", format, args...) }
+func (l *stdLogger) Errorf(format string, args ...interface{}) { l.logf(LevelError, "ERROR", format, args...) }
+
+func (l *stdLogger) logf(level Level, tag, format string, args ...interface{}) {
+	if level < l.level {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s %s %s\n", time.Now().Format(time.RFC3339), tag, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,135 @@
+package mesoslog
+
+// This file declares the Mesos state.json shapes and small value types the
+// rest of the package (client.go, apiv1.go, cache.go, hub.go) has always
+// built on: master/slave state, a single task and its sandbox slave, and the
+// stream identifier used across GetLog/TailLog.
+
+// LogType identifies which Mesos sandbox stream a log operation targets.
+type LogType int
+
+const (
+	STDOUT LogType = iota
+	STDERR
+)
+
+// String renders a LogType the way it appears in sandbox file names and CLI
+// flags: "stdout" or "stderr".
+func (l LogType) String() string {
+	if l == STDERR {
+		return "stderr"
+	}
+	return "stdout"
+}
+
+// LogOut is the content retrieved for a single task by GetLog: Log holds the
+// file contents, or the path it was written to when a download dir was given.
+type LogOut struct {
+	TaskID string
+	AppID  string
+	Log    string
+}
+
+// mstateTaskStatus is one entry in a task's status history, as reported by
+// state.json.
+type mstateTaskStatus struct {
+	State     string  `json:"state"`
+	Timestamp float64 `json:"timestamp"`
+}
+
+// mstateTask is a single task entry from master state.json.
+type mstateTask struct {
+	ID          string              `json:"id"`
+	Name        string              `json:"name"`
+	SlaveID     string              `json:"slave_id"`
+	FrameworkID string              `json:"framework_id"`
+	ExecutorID  string              `json:"executor_id"`
+	Statuses    []*mstateTaskStatus `json:"statuses"`
+
+	lastState *mstateTaskStatus
+}
+
+// UpdateLastState caches the task's most recent status so later lookups
+// (SortTasksByLatestTimestamp) don't each need to re-scan Statuses.
+func (t *mstateTask) UpdateLastState(s *mstateTaskStatus) {
+	t.lastState = s
+}
+
+// mstateFramework is a framework entry from master state.json.
+type mstateFramework struct {
+	ID             string        `json:"id"`
+	Tasks          []*mstateTask `json:"tasks"`
+	CompletedTasks []*mstateTask `json:"completed_tasks"`
+}
+
+// mstateSlave is a slave/agent entry from master state.json.
+type mstateSlave struct {
+	ID       string `json:"id"`
+	Hostname string `json:"hostname"`
+	Pid      string `json:"pid"`
+}
+
+// masterState is the subset of Mesos master state.json this package reads.
+type masterState struct {
+	Frameworks []*mstateFramework `json:"frameworks"`
+	Slaves     []*mstateSlave     `json:"slaves"`
+}
+
+// mstateExecutor is an executor entry from a slave's state.json; Directory
+// is the sandbox path findDirectory resolves for a task.
+type mstateExecutor struct {
+	ID        string `json:"id"`
+	Directory string `json:"directory"`
+}
+
+// mstateSlaveFramework is a framework entry from a slave's (rather than the
+// master's) state.json, keyed by the executors it's running.
+type mstateSlaveFramework struct {
+	ID                 string            `json:"id"`
+	Executors          []*mstateExecutor `json:"executors"`
+	CompletedExecutors []*mstateExecutor `json:"completed_executors"`
+}
+
+// slaveState is the subset of a slave's state.json this package reads.
+type slaveState struct {
+	Frameworks []*mstateSlaveFramework `json:"frameworks"`
+}
+
+// taskInfo groups a matched app's running and completed tasks, as returned
+// by findTask.
+type taskInfo struct {
+	Tasks          []*mstateTask
+	CompletedTasks []*mstateTask
+}
+
+// slaveInfo is everything GetLog/TailLog need about the slave hosting a
+// task: its master-state entry, its own state.json, and the task's sandbox
+// directory on it.
+type slaveInfo struct {
+	Slave     *mstateSlave
+	State     *slaveState
+	Directory string
+}
+
+// readData mirrors the shape of a files/read.json response.
+type readData struct {
+	Data   string `json:"data"`
+	Offset int    `json:"offset"`
+}
+
+// SortTasksByLatestTimestamp sorts tasks newest-first by their most recent
+// status timestamp, so GetLog's ShowLatestOnly can just take tasks[:1].
+type SortTasksByLatestTimestamp []*mstateTask
+
+func (s SortTasksByLatestTimestamp) Len() int      { return len(s) }
+func (s SortTasksByLatestTimestamp) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s SortTasksByLatestTimestamp) Less(i, j int) bool {
+	return latestTimestamp(s[i]) > latestTimestamp(s[j])
+}
+
+func latestTimestamp(t *mstateTask) float64 {
+	if t.lastState != nil {
+		return t.lastState.Timestamp
+	}
+	return 0
+}
@@ -0,0 +1,118 @@
+package mesoslog
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cheggaaa/pb"
+	"github.com/mattn/go-isatty"
+)
+
+// ProgressReporter receives byte-level progress updates while a sandbox file
+// is downloaded. Once a transfer has started, Finish is called when it ends,
+// whether it completed, failed, or was canceled; it may be called more than
+// once (the CLI's signal handler also calls it), so implementations must
+// tolerate repeated calls.
+type ProgressReporter interface {
+	Start(total int64)
+	Add(n int64)
+	Finish()
+}
+
+type noopProgress struct{}
+
+func (noopProgress) Start(int64) {}
+func (noopProgress) Add(int64)   {}
+func (noopProgress) Finish()     {}
+
+// NoProgress is a ProgressReporter that discards all updates, used for
+// --silent/--no-progress runs and non-interactive callers.
+var NoProgress ProgressReporter = noopProgress{}
+
+// IsTTY reports whether stdout is attached to a terminal - the condition
+// under which a caller should prefer NewTTYProgress over NoProgress.
+func IsTTY() bool {
+	return isatty.IsTerminal(os.Stdout.Fd())
+}
+
+// ttyProgress renders a pb.ProgressBar. It's updated on a fixed tick rather
+// than on every Add call so a fast counting reader doesn't thrash the
+// terminal with redraws.
+type ttyProgress struct {
+	bar        *pb.ProgressBar
+	current    int64
+	stop       chan struct{}
+	done       chan struct{}
+	finishOnce sync.Once
+}
+
+// NewTTYProgress creates a ProgressReporter that renders a speed-aware
+// progress bar to stdout.
+func NewTTYProgress(label string) ProgressReporter {
+	bar := pb.New64(0)
+	bar.ShowSpeed = true
+	bar.SetMaxWidth(100)
+	bar.Prefix(label + " ")
+	bar.ManualUpdate = true
+	bar.Start()
+
+	p := &ttyProgress{bar: bar, stop: make(chan struct{}), done: make(chan struct{})}
+	go p.tick()
+	return p
+}
+
+func (p *ttyProgress) Start(total int64) {
+	p.bar.SetTotal64(total)
+}
+
+func (p *ttyProgress) Add(n int64) {
+	atomic.AddInt64(&p.current, n)
+}
+
+func (p *ttyProgress) tick() {
+	defer close(p.done)
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.bar.Set64(atomic.LoadInt64(&p.current))
+			p.bar.Update()
+		case <-p.stop:
+			p.bar.Set64(atomic.LoadInt64(&p.current))
+			p.bar.Update()
+			return
+		}
+	}
+}
+
+// Finish stops the ticker and leaves the bar at its final position. Safe to
+// call even if the transfer was canceled partway through, and safe to call
+// more than once - callers on both the cancellation path and the normal
+// completion path may both invoke it.
+func (p *ttyProgress) Finish() {
+	p.finishOnce.Do(func() {
+		close(p.stop)
+		<-p.done
+		p.bar.Finish()
+	})
+}
+
+// countingReader wraps an io.Reader, reporting every Read to a
+// ProgressReporter regardless of whether the response is buffered in memory
+// or streamed straight to disk.
+type countingReader struct {
+	r        io.Reader
+	progress ProgressReporter
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	if n > 0 {
+		cr.progress.Add(int64(n))
+	}
+	return n, err
+}